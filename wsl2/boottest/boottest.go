@@ -0,0 +1,133 @@
+// Package boottest smoke-boots a freshly built bzImage under QEMU before it
+// is trusted enough to deploy, so a kernel that panics or hangs on boot never
+// gets copied over a working one.
+package boottest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// BootOKMarker is printed by the cached initramfs's init script once the
+// kernel has booted far enough to run userspace.
+const BootOKMarker = "BOOTTEST_BOOT_OK"
+
+// Config describes a single smoke-boot attempt.
+type Config struct {
+	KernelPath    string        // path to the built bzImage
+	InitramfsPath string        // path to a minimal busybox initramfs (see EnsureInitramfs)
+	MemoryMB      int           // 0 means 512
+	CPUs          int           // 0 means 1
+	KASLR         bool          // false appends kaslr=off to the kernel command line
+	Timeout       time.Duration // 0 means 60s
+}
+
+// Result is the structured pass/fail outcome of a smoke boot, suitable for
+// gating CI.
+type Result struct {
+	Passed     bool
+	Reason     string
+	SerialLog  string
+	BootedInMS int64
+}
+
+// Run boots cfg.KernelPath under qemu-system-x86_64 with cfg.InitramfsPath as
+// the initrd, waiting for BootOKMarker on the serial console. It never
+// returns an error for a failed or panicked boot - that's reported via
+// Result - only for problems launching QEMU itself.
+func Run(cfg Config) (Result, error) {
+	memoryMB := cfg.MemoryMB
+	if memoryMB == 0 {
+		memoryMB = 512
+	}
+	cpus := cfg.CPUs
+	if cpus == 0 {
+		cpus = 1
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+
+	kernelArgs := "console=ttyS0 panic=1"
+	if !cfg.KASLR {
+		kernelArgs += " kaslr=off"
+	} else {
+		kernelArgs += " kaslr=on"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "qemu-system-x86_64",
+		"-kernel", cfg.KernelPath,
+		"-initrd", cfg.InitramfsPath,
+		"-append", kernelArgs,
+		"-m", fmt.Sprintf("%d", memoryMB),
+		"-smp", fmt.Sprintf("%d", cpus),
+		"-nographic",
+		"-serial", "stdio",
+		"-no-reboot",
+		"-display", "none",
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to attach to qemu stdout: %v", err)
+	}
+	cmd.Stderr = os.Stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return Result{}, fmt.Errorf("failed to start qemu: %v", err)
+	}
+
+	var log strings.Builder
+	booted := false
+	panicked := false
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		log.WriteString(line)
+		log.WriteString("\n")
+
+		if strings.Contains(line, BootOKMarker) {
+			booted = true
+			break
+		}
+		if strings.Contains(line, "Kernel panic") {
+			panicked = true
+			break
+		}
+	}
+
+	_ = cmd.Wait()
+
+	result := Result{
+		SerialLog:  log.String(),
+		BootedInMS: time.Since(start).Milliseconds(),
+	}
+
+	switch {
+	case booted:
+		result.Passed = true
+		result.Reason = "boot marker observed on serial console"
+	case panicked:
+		result.Passed = false
+		result.Reason = "kernel panic observed on serial console"
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Passed = false
+		result.Reason = fmt.Sprintf("timed out after %s waiting for boot marker", timeout)
+	default:
+		result.Passed = false
+		result.Reason = "qemu exited before the boot marker was observed"
+	}
+
+	return result, nil
+}
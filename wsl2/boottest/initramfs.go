@@ -0,0 +1,93 @@
+package boottest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// initScript is the initramfs's /init. It prints BootOKMarker as soon as
+// userspace is reached and then powers the VM off, so Run's serial scan can
+// stop the moment it sees the marker.
+const initScript = `#!/bin/sh
+echo ` + BootOKMarker + `
+poweroff -f
+`
+
+// EnsureInitramfs returns the path to a minimal busybox-based initramfs
+// under cacheDir, building it once with the host's busybox binary and
+// reusing it on subsequent calls.
+func EnsureInitramfs(cacheDir string) (string, error) {
+	initramfsPath := filepath.Join(cacheDir, "boottest-initramfs.cpio.gz")
+	if _, err := os.Stat(initramfsPath); err == nil {
+		return initramfsPath, nil
+	}
+
+	busybox, err := exec.LookPath("busybox")
+	if err != nil {
+		return "", fmt.Errorf("busybox not found in PATH, required to build the smoke-test initramfs: %v", err)
+	}
+
+	buildDir, err := os.MkdirTemp(cacheDir, "initramfs-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create initramfs build dir: %v", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	if err := os.MkdirAll(filepath.Join(buildDir, "bin"), 0755); err != nil {
+		return "", err
+	}
+
+	if err := copyFile(busybox, filepath.Join(buildDir, "bin", "busybox")); err != nil {
+		return "", fmt.Errorf("failed to stage busybox: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(buildDir, "bin", "busybox"), 0755); err != nil {
+		return "", err
+	}
+
+	installCmd := exec.Command("./busybox", "--install", "-s", ".")
+	installCmd.Dir = filepath.Join(buildDir, "bin")
+	if out, err := installCmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("busybox --install failed: %v: %s", err, out)
+	}
+
+	if err := os.WriteFile(filepath.Join(buildDir, "init"), []byte(initScript), 0755); err != nil {
+		return "", fmt.Errorf("failed to write init script: %v", err)
+	}
+
+	out, err := os.Create(initramfsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create initramfs output: %v", err)
+	}
+	defer out.Close()
+
+	findCmd := exec.Command("sh", "-c", "find . | cpio -o -H newc | gzip -9")
+	findCmd.Dir = buildDir
+	findCmd.Stdout = out
+	findCmd.Stderr = os.Stderr
+	if err := findCmd.Run(); err != nil {
+		os.Remove(initramfsPath)
+		return "", fmt.Errorf("failed to package initramfs: %v", err)
+	}
+
+	return initramfsPath, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
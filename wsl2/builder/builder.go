@@ -0,0 +1,40 @@
+// Package builder abstracts how a kernel tree actually gets compiled, so the
+// same phase1 flow can build directly on the host or inside a pinned
+// container image.
+package builder
+
+import (
+	"fmt"
+	"time"
+)
+
+// BuildParams describes a single kernel build, independent of where it runs.
+type BuildParams struct {
+	KernelDir  string        // path to the kernel source tree
+	ConfigPath string        // KCONFIG_CONFIG path, relative to KernelDir
+	Jobs       int           // make -jN; 0 means the builder should pick a default
+	OutputDir  string        // where modules/headers tarballs are written
+	Env        []string      // extra NAME=value entries appended to the build environment
+	Timeout    time.Duration // 0 means no timeout
+}
+
+// Builder compiles a kernel tree according to params and returns the paths
+// to the built bzImage and the packaged modules/headers.
+type Builder interface {
+	Build(params BuildParams) (bzImagePath, modulesTar, headersTar string, err error)
+}
+
+// New returns the Builder named by builderName ("host", "docker", or
+// "podman"). image is only used by the container builders.
+func New(builderName, image string) (Builder, error) {
+	switch builderName {
+	case "", "host":
+		return HostBuilder{}, nil
+	case "docker":
+		return ContainerBuilder{Engine: "docker", Image: image}, nil
+	case "podman":
+		return ContainerBuilder{Engine: "podman", Image: image}, nil
+	default:
+		return nil, fmt.Errorf("unknown builder %q (want host, docker, or podman)", builderName)
+	}
+}
@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ContainerBuilder runs the build inside a Docker or Podman container pinned
+// to Image, keeping the host free of build-essential and friends and making
+// builds cacheable by image digest.
+type ContainerBuilder struct {
+	Engine string // "docker" or "podman"
+	Image  string
+}
+
+func (c ContainerBuilder) Build(params BuildParams) (string, string, string, error) {
+	if c.Image == "" {
+		return "", "", "", fmt.Errorf("%s builder requires --builder-image", c.Engine)
+	}
+
+	jobs := params.Jobs
+	if jobs < 1 {
+		jobs = 4
+	}
+
+	modulesDir := filepath.Join(params.OutputDir, "modules")
+	headersDir := filepath.Join(params.OutputDir, "headers")
+	if err := os.MkdirAll(modulesDir, 0755); err != nil {
+		return "", "", "", fmt.Errorf("failed to create modules output dir: %v", err)
+	}
+	if err := os.MkdirAll(headersDir, 0755); err != nil {
+		return "", "", "", fmt.Errorf("failed to create headers output dir: %v", err)
+	}
+
+	buildScript := fmt.Sprintf(`set -e
+apt-get update
+apt-get install -y build-essential flex bison libssl-dev libelf-dev bc python3 pahole cpio
+make -j%d KCONFIG_CONFIG=%s
+make modules_install headers_install INSTALL_MOD_PATH=/out/modules INSTALL_HDR_PATH=/out/headers
+tar -C /out/modules -czf /out/modules.tar.gz .
+tar -C /out/headers -czf /out/headers.tar.gz .
+`, jobs, params.ConfigPath)
+
+	containerName := fmt.Sprintf("wsl2-kernel-build-%d", os.Getpid())
+	args := []string{"run", "--rm", "--name", containerName,
+		"-v", params.KernelDir + ":/kernel",
+		"-v", params.OutputDir + ":/out",
+		"-w", "/kernel",
+	}
+	for _, env := range params.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, c.Image, "bash", "-c", buildScript)
+
+	cmd := exec.Command(c.Engine, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Killing the docker/podman client process on timeout isn't enough:
+	// with --rm the container keeps running server-side until something
+	// kills it by name too.
+	if err := runContainerWithTimeout(cmd, params.Timeout, c.Engine, containerName); err != nil {
+		return "", "", "", fmt.Errorf("container build failed: %v", err)
+	}
+
+	bzImagePath := filepath.Join(params.KernelDir, "arch", "x86", "boot", "bzImage")
+	if _, err := os.Stat(bzImagePath); err != nil {
+		return "", "", "", fmt.Errorf("kernel image not found at %s - build may have failed", bzImagePath)
+	}
+
+	return bzImagePath, filepath.Join(params.OutputDir, "modules.tar.gz"), filepath.Join(params.OutputDir, "headers.tar.gz"), nil
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// HostBuilder builds the kernel directly on the current machine, installing
+// build dependencies via apt. This preserves the tool's original behavior.
+type HostBuilder struct{}
+
+func (HostBuilder) Build(params BuildParams) (string, string, string, error) {
+	// One deadline shared across every step below, so params.Timeout bounds
+	// the whole build instead of being re-applied to each step in turn (apt
+	// update, apt install, make, modules_install could otherwise each run
+	// for the full timeout, adding up to 4x the configured budget).
+	dl := newDeadline(params.Timeout)
+
+	if err := dl.run(exec.Command("sudo", "apt", "update")); err != nil {
+		return "", "", "", fmt.Errorf("failed to update packages: %v", err)
+	}
+
+	installArgs := []string{"apt", "install", "-y",
+		"build-essential", "flex", "bison", "libssl-dev",
+		"libelf-dev", "bc", "python3", "pahole", "cpio"}
+	if err := dl.run(exec.Command("sudo", installArgs...)); err != nil {
+		return "", "", "", fmt.Errorf("failed to install dependencies: %v", err)
+	}
+
+	jobs := params.Jobs
+	if jobs < 1 {
+		jobs = 4
+	}
+
+	buildCmd := exec.Command("make", fmt.Sprintf("-j%d", jobs), "KCONFIG_CONFIG="+params.ConfigPath)
+	buildCmd.Dir = params.KernelDir
+	buildCmd.Env = append(os.Environ(), params.Env...)
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	if err := dl.run(buildCmd); err != nil {
+		return "", "", "", fmt.Errorf("failed to build kernel: %v", err)
+	}
+
+	modulesCmd := exec.Command("sudo", "make", "modules_install", "headers_install")
+	modulesCmd.Dir = params.KernelDir
+	modulesCmd.Stdout = os.Stdout
+	modulesCmd.Stderr = os.Stderr
+	if err := dl.run(modulesCmd); err != nil {
+		return "", "", "", fmt.Errorf("failed to install modules: %v", err)
+	}
+
+	bzImagePath := filepath.Join(params.KernelDir, "arch", "x86", "boot", "bzImage")
+	if _, err := os.Stat(bzImagePath); err != nil {
+		return "", "", "", fmt.Errorf("kernel image not found at %s - build may have failed", bzImagePath)
+	}
+
+	// modules_install above installed straight into the host's module tree
+	// (and headers_install into the kernel tree's usr/) rather than packaging
+	// tarballs, matching prior behavior.
+	return bzImagePath, "", "", nil
+}
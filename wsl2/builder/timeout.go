@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// runWithTimeout runs cmd to completion, killing it if it hasn't finished
+// within timeout. A zero timeout means wait indefinitely.
+func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		_ = cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("command timed out after %s: %s", timeout, cmd.Path)
+	}
+}
+
+// runContainerWithTimeout is like runWithTimeout, but for a `docker`/`podman
+// run` client process: killing that client alone leaves a --rm container
+// running server-side, so on timeout it also issues `engine kill
+// containerName` to actually stop the build.
+func runContainerWithTimeout(cmd *exec.Cmd, timeout time.Duration, engine, containerName string) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		_ = exec.Command(engine, "kill", containerName).Run()
+		_ = cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("command timed out after %s: %s", timeout, cmd.Path)
+	}
+}
+
+// deadline tracks a single timeout budget shared across several sequential
+// commands, so a multi-step build doesn't apply the full per-step timeout to
+// every step independently. A zero-value deadline never expires.
+type deadline struct {
+	at time.Time
+}
+
+// newDeadline starts a deadline counting down from timeout, or a deadline
+// that never expires if timeout is zero.
+func newDeadline(timeout time.Duration) deadline {
+	if timeout <= 0 {
+		return deadline{}
+	}
+	return deadline{at: time.Now().Add(timeout)}
+}
+
+// run executes cmd against whatever time is left on the deadline, failing
+// immediately without starting cmd if the deadline has already passed.
+func (d deadline) run(cmd *exec.Cmd) error {
+	if d.at.IsZero() {
+		return runWithTimeout(cmd, 0)
+	}
+
+	remaining := time.Until(d.at)
+	if remaining <= 0 {
+		return fmt.Errorf("build timed out before running: %s", cmd.Path)
+	}
+	return runWithTimeout(cmd, remaining)
+}
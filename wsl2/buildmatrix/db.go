@@ -0,0 +1,92 @@
+package buildmatrix
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Result records the outcome of building a single Target.
+type Result struct {
+	Target          string
+	RepoSHA         string
+	StartedAt       int64
+	DurationSeconds float64
+	BzImageSHA256   string
+	KernelRelease   string
+	CompilerVersion string
+	Success         bool
+	ErrorMessage    string
+}
+
+// DB is a SQLite-backed log of build results, used to bisect regressions
+// across kernel versions and to power --only-changed.
+type DB struct {
+	conn *sql.DB
+}
+
+// OpenDB opens (creating if necessary) the SQLite build log at path.
+func OpenDB(path string) (*DB, error) {
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open build log %s: %v", path, err)
+	}
+
+	const schema = `
+CREATE TABLE IF NOT EXISTS builds (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	target           TEXT NOT NULL,
+	repo_sha         TEXT NOT NULL,
+	started_at       INTEGER NOT NULL,
+	duration_seconds REAL NOT NULL,
+	bzimage_sha256   TEXT NOT NULL,
+	kernel_release   TEXT NOT NULL,
+	compiler_version TEXT NOT NULL,
+	success          INTEGER NOT NULL,
+	error_message    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_builds_target ON builds(target);
+`
+	if _, err := conn.Exec(schema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to initialize build log schema: %v", err)
+	}
+
+	return &DB{conn: conn}, nil
+}
+
+// RecordResult appends a build result to the log.
+func (db *DB) RecordResult(r Result) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO builds (target, repo_sha, started_at, duration_seconds, bzimage_sha256, kernel_release, compiler_version, success, error_message)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Target, r.RepoSHA, r.StartedAt, r.DurationSeconds, r.BzImageSHA256, r.KernelRelease, r.CompilerVersion, r.Success, r.ErrorMessage,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record build result for %s: %v", r.Target, err)
+	}
+	return nil
+}
+
+// LastSuccessfulRepoSHA returns the repo HEAD sha of the most recent
+// successful build of target, or "" if there isn't one.
+func (db *DB) LastSuccessfulRepoSHA(target string) (string, error) {
+	var sha string
+	err := db.conn.QueryRow(
+		`SELECT repo_sha FROM builds WHERE target = ? AND success = 1 ORDER BY started_at DESC LIMIT 1`,
+		target,
+	).Scan(&sha)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up last successful build of %s: %v", target, err)
+	}
+	return sha, nil
+}
+
+// Close closes the underlying database connection.
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
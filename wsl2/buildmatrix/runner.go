@@ -0,0 +1,283 @@
+package buildmatrix
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/remeh/sizedwaitgroup"
+)
+
+// Options controls how a build matrix is executed.
+type Options struct {
+	Threads      int            // worker pool size; 0 means 1
+	Jobs         int            // make -jN per build; 0 means nproc
+	Shuffle      bool           // randomize target order
+	KernelFilter *regexp.Regexp // if set, only targets whose name matches run
+	OnlyChanged  bool           // skip targets whose repo HEAD matches the last successful build
+	WorkDir      string         // directory under which each target is cloned
+	DestDir      string         // directory each target's bzImage is copied into
+	Log          func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Log != nil {
+		o.Log(format, args...)
+	}
+}
+
+// Select filters and orders targets according to opts.KernelFilter,
+// opts.Shuffle, and (given db) opts.OnlyChanged.
+func Select(targets []Target, opts Options, db *DB) ([]Target, error) {
+	selected := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		if opts.KernelFilter != nil && !opts.KernelFilter.MatchString(t.Name) {
+			continue
+		}
+		selected = append(selected, t)
+	}
+
+	if opts.OnlyChanged && db != nil {
+		changed := make([]Target, 0, len(selected))
+		for _, t := range selected {
+			headSHA, err := remoteHeadSHA(t.RepoURL, t.Branch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check remote HEAD for %s: %v", t.Name, err)
+			}
+
+			lastSHA, err := db.LastSuccessfulRepoSHA(t.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if headSHA == lastSHA {
+				opts.logf("skipping %s: HEAD %s already built successfully", t.Name, headSHA[:min(7, len(headSHA))])
+				continue
+			}
+			changed = append(changed, t)
+		}
+		selected = changed
+	}
+
+	if opts.Shuffle {
+		rand.New(rand.NewSource(time.Now().UnixNano())).Shuffle(len(selected), func(i, j int) {
+			selected[i], selected[j] = selected[j], selected[i]
+		})
+	}
+
+	return selected, nil
+}
+
+// Run builds every target in targets over a worker pool sized by
+// opts.Threads, recording each result to db (if non-nil) as it completes.
+func Run(targets []Target, opts Options, db *DB) []Result {
+	threads := opts.Threads
+	if threads < 1 {
+		threads = 1
+	}
+
+	if opts.WorkDir != "" {
+		if err := os.MkdirAll(opts.WorkDir, 0755); err != nil {
+			opts.logf("failed to create work dir %s: %v", opts.WorkDir, err)
+		}
+	}
+	if opts.DestDir != "" {
+		if err := os.MkdirAll(opts.DestDir, 0755); err != nil {
+			opts.logf("failed to create dest dir %s: %v", opts.DestDir, err)
+		}
+	}
+
+	swg := sizedwaitgroup.New(threads)
+	results := make([]Result, len(targets))
+
+	for i, t := range targets {
+		swg.Add()
+		go func(i int, t Target) {
+			defer swg.Done()
+			opts.logf("starting build: %s", t.Name)
+			result := buildTarget(t, opts)
+			if result.Success {
+				opts.logf("build succeeded: %s (%.1fs)", t.Name, result.DurationSeconds)
+			} else {
+				opts.logf("build failed: %s: %s", t.Name, result.ErrorMessage)
+			}
+			if db != nil {
+				if err := db.RecordResult(result); err != nil {
+					opts.logf("failed to record result for %s: %v", t.Name, err)
+				}
+			}
+			results[i] = result
+		}(i, t)
+	}
+	swg.Wait()
+
+	return results
+}
+
+// buildTarget clones (if needed), patches, and builds a single target,
+// returning a populated Result regardless of success or failure.
+func buildTarget(t Target, opts Options) Result {
+	startedAt := time.Now()
+	result := Result{Target: t.Name, StartedAt: startedAt.Unix()}
+
+	fail := func(err error) Result {
+		result.Success = false
+		result.ErrorMessage = err.Error()
+		result.DurationSeconds = time.Since(startedAt).Seconds()
+		return result
+	}
+
+	targetDir := filepath.Join(opts.WorkDir, t.Name)
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		// targetDir already has opts.WorkDir as its prefix, so clone with
+		// the process's own working directory rather than opts.WorkDir
+		// again - otherwise this clones into WorkDir/WorkDir/name while
+		// everything below looks for it at WorkDir/name.
+		if err := run("", "git", "clone", "--depth=1", "-b", t.Branch, t.RepoURL, targetDir); err != nil {
+			return fail(fmt.Errorf("clone failed: %v", err))
+		}
+	}
+
+	repoSHA, err := runOutput(targetDir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return fail(fmt.Errorf("failed to read repo HEAD: %v", err))
+	}
+	result.RepoSHA = repoSHA
+
+	for _, patch := range t.Patches {
+		if err := runWithStdin(targetDir, patch, "patch", "-p1"); err != nil {
+			return fail(fmt.Errorf("failed to apply patch %s: %v", patch, err))
+		}
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		if nproc, err := runOutput(targetDir, "nproc"); err == nil {
+			if n, err := strconv.Atoi(nproc); err == nil {
+				jobs = n
+			}
+		}
+		if jobs < 1 {
+			jobs = 4
+		}
+	}
+
+	if err := run(targetDir, "make", fmt.Sprintf("-j%d", jobs), "KCONFIG_CONFIG="+t.ConfigPath); err != nil {
+		return fail(fmt.Errorf("build failed: %v", err))
+	}
+
+	bzImagePath := filepath.Join(targetDir, "arch", "x86", "boot", "bzImage")
+	sha, err := sha256File(bzImagePath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to hash bzImage: %v", err))
+	}
+	result.BzImageSHA256 = sha
+
+	release, err := os.ReadFile(filepath.Join(targetDir, "include", "config", "kernel.release"))
+	if err == nil {
+		result.KernelRelease = strings.TrimSpace(string(release))
+	}
+
+	if version, err := runOutput(targetDir, "cc", "--version"); err == nil {
+		result.CompilerVersion = strings.SplitN(version, "\n", 2)[0]
+	}
+
+	if opts.DestDir != "" {
+		destPath := filepath.Join(opts.DestDir, t.DestFilename)
+		if err := copyFile(bzImagePath, destPath); err != nil {
+			return fail(fmt.Errorf("failed to copy bzImage to %s: %v", destPath, err))
+		}
+	}
+
+	result.Success = true
+	result.DurationSeconds = time.Since(startedAt).Seconds()
+	return result
+}
+
+func remoteHeadSHA(repoURL, branch string) (string, error) {
+	out, err := runOutput("", "git", "ls-remote", repoURL, branch)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no ref %q found on %s", branch, repoURL)
+	}
+	return fields[0], nil
+}
+
+func run(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func runOutput(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func runWithStdin(dir, stdinFile, name string, args ...string) error {
+	f, err := os.Open(stdinFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = f
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
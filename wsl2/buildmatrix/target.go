@@ -0,0 +1,52 @@
+// Package buildmatrix drives a matrix of kernel build targets defined in a
+// TOML config, dispatching builds over a bounded worker pool and logging
+// results to SQLite so regressions can be bisected across kernel versions.
+package buildmatrix
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Target describes a single kernel build: where to get the source, which
+// config to build it with, and where the resulting bzImage should go.
+type Target struct {
+	Name         string   `toml:"name"`
+	RepoURL      string   `toml:"repo"`
+	Branch       string   `toml:"branch"`
+	ConfigPath   string   `toml:"config"`
+	Patches      []string `toml:"patches"`
+	DestFilename string   `toml:"dest"`
+}
+
+// matrixFile is the top-level shape of the TOML config: a list of [[target]]
+// tables.
+type matrixFile struct {
+	Targets []Target `toml:"target"`
+}
+
+// LoadMatrix parses a TOML build matrix file into a list of targets.
+func LoadMatrix(path string) ([]Target, error) {
+	var mf matrixFile
+	if _, err := toml.DecodeFile(path, &mf); err != nil {
+		return nil, fmt.Errorf("failed to parse build matrix %s: %v", path, err)
+	}
+
+	for i, t := range mf.Targets {
+		if t.Name == "" {
+			return nil, fmt.Errorf("target %d in %s is missing a name", i, path)
+		}
+		if t.RepoURL == "" {
+			return nil, fmt.Errorf("target %q in %s is missing a repo", t.Name, path)
+		}
+		if t.ConfigPath == "" {
+			return nil, fmt.Errorf("target %q in %s is missing a config", t.Name, path)
+		}
+		if t.DestFilename == "" {
+			mf.Targets[i].DestFilename = t.Name + "-bzImage"
+		}
+	}
+
+	return mf.Targets, nil
+}
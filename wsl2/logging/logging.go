@@ -0,0 +1,112 @@
+// Package logging provides the structured, leveled logger used throughout
+// wsl2: colorized human-readable text on a terminal, or newline-delimited
+// JSON for machine consumption, with an optional file sink that always
+// receives raw NDJSON regardless of the console format.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// Field is a single structured key/value pair attached to a log event.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F builds a Field. It exists so call sites read as logging.F("key", value)
+// instead of a bare struct literal.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Interface is the logger surface used by the rest of wsl2. It is an
+// interface rather than a concrete type so tests (and future backends)
+// can substitute their own implementation.
+type Interface interface {
+	Trace(message string, fields ...Field)
+	Debug(message string, fields ...Field)
+	Info(message string, fields ...Field)
+	Warn(message string, fields ...Field)
+	Error(message string, fields ...Field)
+
+	// WithCorrelationID returns a copy of the logger that stamps every
+	// subsequent event with correlationID, so Phase 1 and Phase 2 logs for
+	// the same run can be stitched back together.
+	WithCorrelationID(correlationID string) Interface
+}
+
+// Options configures New.
+type Options struct {
+	// Format is "text" (colorized console output) or "json" (raw NDJSON).
+	// Defaults to "text".
+	Format string
+	// Level is one of trace, debug, info, warn, error. Defaults to "info".
+	Level string
+	// FilePath, if set, additionally tees raw NDJSON events to this file,
+	// regardless of Format.
+	FilePath string
+}
+
+type logger struct {
+	zl            zerolog.Logger
+	correlationID string
+}
+
+// New builds an Interface from opts.
+func New(opts Options) (Interface, error) {
+	level := zerolog.InfoLevel
+	if opts.Level != "" {
+		parsed, err := zerolog.ParseLevel(opts.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %v", opts.Level, err)
+		}
+		level = parsed
+	}
+
+	var consoleWriter io.Writer = os.Stderr
+	if opts.Format != "json" {
+		consoleWriter = zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "15:04:05"}
+	}
+
+	writer := consoleWriter
+	if opts.FilePath != "" {
+		file, err := os.OpenFile(opts.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file %s: %v", opts.FilePath, err)
+		}
+		// file always receives the raw JSON event; only the console side
+		// gets reformatted by zerolog.ConsoleWriter above.
+		writer = zerolog.MultiLevelWriter(consoleWriter, file)
+	}
+
+	zl := zerolog.New(writer).Level(level).With().Timestamp().Logger()
+	return &logger{zl: zl}, nil
+}
+
+func (l *logger) event(level zerolog.Level, message string, fields []Field) {
+	evt := l.zl.WithLevel(level)
+	if l.correlationID != "" {
+		evt = evt.Str("correlation_id", l.correlationID)
+	}
+	for _, f := range fields {
+		evt = evt.Interface(f.Key, f.Value)
+	}
+	evt.Msg(message)
+}
+
+func (l *logger) Trace(message string, fields ...Field) { l.event(zerolog.TraceLevel, message, fields) }
+func (l *logger) Debug(message string, fields ...Field) { l.event(zerolog.DebugLevel, message, fields) }
+func (l *logger) Info(message string, fields ...Field)  { l.event(zerolog.InfoLevel, message, fields) }
+func (l *logger) Warn(message string, fields ...Field)  { l.event(zerolog.WarnLevel, message, fields) }
+func (l *logger) Error(message string, fields ...Field) { l.event(zerolog.ErrorLevel, message, fields) }
+
+func (l *logger) WithCorrelationID(correlationID string) Interface {
+	clone := *l
+	clone.correlationID = correlationID
+	return &clone
+}
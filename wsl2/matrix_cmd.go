@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/x86txt/tipsntricks/wsl2/buildmatrix"
+)
+
+// runMatrixCLI parses `matrix` subcommand flags and runs the build matrix.
+func runMatrixCLI(args []string) error {
+	fs := flag.NewFlagSet("matrix", flag.ExitOnError)
+	file := fs.String("file", "kernels.toml", "TOML build matrix file")
+	dbPath := fs.String("db", "buildmatrix.db", "SQLite build log path")
+	workDir := fs.String("work-dir", "build", "Directory each target is cloned into")
+	destDir := fs.String("dest-dir", ".", "Directory each target's bzImage is copied into")
+	threads := fs.Int("threads", 1, "Number of targets to build in parallel")
+	jobs := fs.Int("jobs", 0, "make -jN per build (0 = nproc)")
+	shuffle := fs.Bool("shuffle", false, "Randomize target build order")
+	kernel := fs.String("kernel", "", "Only build targets whose name matches this regex")
+	onlyChanged := fs.Bool("only-changed", false, "Skip targets whose repo HEAD matches the last successful build")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return runBuildMatrix(matrixOptions{
+		file:        *file,
+		dbPath:      *dbPath,
+		workDir:     *workDir,
+		destDir:     *destDir,
+		threads:     *threads,
+		jobs:        *jobs,
+		shuffle:     *shuffle,
+		kernel:      *kernel,
+		onlyChanged: *onlyChanged,
+	})
+}
+
+// matrixOptions holds the `matrix` subcommand flags parsed by runMatrixCLI.
+type matrixOptions struct {
+	file        string
+	dbPath      string
+	workDir     string
+	destDir     string
+	threads     int
+	jobs        int
+	shuffle     bool
+	kernel      string
+	onlyChanged bool
+}
+
+// runBuildMatrix loads a TOML build matrix and builds every selected target
+// over a bounded worker pool, logging results to SQLite.
+func runBuildMatrix(opts matrixOptions) error {
+	targets, err := buildmatrix.LoadMatrix(opts.file)
+	if err != nil {
+		return err
+	}
+
+	db, err := buildmatrix.OpenDB(opts.dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var kernelFilter *regexp.Regexp
+	if opts.kernel != "" {
+		kernelFilter, err = regexp.Compile(opts.kernel)
+		if err != nil {
+			return fmt.Errorf("invalid --kernel filter %q: %v", opts.kernel, err)
+		}
+	}
+
+	runOpts := buildmatrix.Options{
+		Threads:      opts.threads,
+		Jobs:         opts.jobs,
+		Shuffle:      opts.shuffle,
+		KernelFilter: kernelFilter,
+		OnlyChanged:  opts.onlyChanged,
+		WorkDir:      opts.workDir,
+		DestDir:      opts.destDir,
+		Log: func(format string, args ...interface{}) {
+			fmt.Printf(format+"\n", args...)
+		},
+	}
+
+	selected, err := buildmatrix.Select(targets, runOpts, db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Building %d of %d targets (threads=%d)\n", len(selected), len(targets), opts.threads)
+	results := buildmatrix.Run(selected, runOpts, db)
+
+	failures := 0
+	for _, r := range results {
+		if !r.Success {
+			failures++
+		}
+	}
+
+	fmt.Printf("Build matrix complete: %d succeeded, %d failed\n", len(results)-failures, failures)
+	if failures > 0 {
+		return fmt.Errorf("%d of %d targets failed", failures, len(results))
+	}
+	return nil
+}
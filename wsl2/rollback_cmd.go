@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/x86txt/tipsntricks/wsl2/wslconfig"
+)
+
+// runRollbackCLI restores the most recent .wslconfig backup and shuts down
+// WSL so the restored kernel/config takes effect on next startup.
+func runRollbackCLI(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	path := fs.String("wslconfig-path", "", "Path to .wslconfig (default: C:/Users/<user>/.wslconfig)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	wslConfigPath := *path
+	if wslConfigPath == "" {
+		windowsUser := os.Getenv("USERNAME")
+		if windowsUser == "" {
+			return fmt.Errorf("could not determine Windows user; pass --wslconfig-path explicitly")
+		}
+		wslConfigPath = fmt.Sprintf("C:/Users/%s/.wslconfig", windowsUser)
+	}
+
+	if err := wslconfig.Rollback(wslConfigPath); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from the most recent backup\n", wslConfigPath)
+
+	cmd := exec.Command("wsl", "--shutdown")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
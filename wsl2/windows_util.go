@@ -0,0 +1,95 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+
+	"github.com/x86txt/tipsntricks/wsl2/wslapi"
+)
+
+const (
+	featureWSL    = "Microsoft-Windows-Subsystem-Linux"
+	featureVMPlat = "VirtualMachinePlatform"
+)
+
+// IsWSLInstalled reports whether wslapi.dll can be loaded on this machine,
+// i.e. whether the WSL optional components are present at all. This has to
+// be a DLL-load probe rather than a call like IsDistributionRegistered:
+// every wslapi func here goes through a LazyDLL, which panics on Call if the
+// DLL can't be found - exactly the "WSL not installed" case this reports.
+func (w *WSL2Automator) IsWSLInstalled() bool {
+	return wslapi.Available()
+}
+
+// syncBeforeShutdown best-effort flushes the default distribution's
+// filesystem via WslLaunch before phase2WindowsTasks forces the WSL VM down.
+// wslapi.dll has no exported "shut down every VM" call - WslLaunch,
+// WslConfigureDistribution, and WslRegisterDistribution are all scoped to a
+// single distribution - so `wsl --shutdown` itself still has to shell out.
+func (w *WSL2Automator) syncBeforeShutdown() {
+	if _, err := wslapi.Launch("", "sync", false); err != nil {
+		w.Logger.Warn(fmt.Sprintf("Failed to sync before shutdown: %v", err))
+	}
+}
+
+// IsWSLFeatureEnabled reports whether both Windows optional features
+// required by WSL2 are enabled.
+func (w *WSL2Automator) IsWSLFeatureEnabled() bool {
+	return w.dismFeatureEnabled(featureWSL) && w.dismFeatureEnabled(featureVMPlat)
+}
+
+// dismFeatureEnabled shells out to a read-only `dism /get-featureinfo` query;
+// enabling a feature requires elevation, but querying state does not.
+func (w *WSL2Automator) dismFeatureEnabled(featureName string) bool {
+	out, err := w.runCommandOutput("dism.exe", "/online", "/get-featureinfo", "/featurename:"+featureName)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(out, "State : Enabled")
+}
+
+// enableWSLFeatures enables the WSL and Virtual Machine Platform optional
+// features via an elevated DISM call. It returns (rebootRequired, error);
+// ERROR_SUCCESS_REBOOT_INITIATED and ERROR_SUCCESS_REBOOT_REQUIRED are
+// treated as success-with-reboot rather than failure.
+func (w *WSL2Automator) enableWSLFeatures() (bool, error) {
+	rebootRequired := false
+
+	for _, feature := range []string{featureWSL, featureVMPlat} {
+		w.Logger.Info(fmt.Sprintf("Enabling Windows feature %s...", feature))
+		exitCode, err := wslapi.EnableOptionalFeature(feature)
+		if err != nil {
+			return false, fmt.Errorf("failed to enable %s: %v", feature, err)
+		}
+
+		switch exitCode {
+		case 0:
+			// enabled, no reboot needed
+		case wslapi.ErrorSuccessRebootInitiated, wslapi.ErrorSuccessRebootRequired:
+			rebootRequired = true
+		default:
+			return false, fmt.Errorf("dism exited with code %d enabling %s", exitCode, feature)
+		}
+	}
+
+	return rebootRequired, nil
+}
+
+// writeWindowsConfigFile writes a Windows-side config file (such as
+// .wslconfig) as UTF-16 LE with a BOM, which some Windows locales require
+// for files read by wsl.exe. Its signature matches os.WriteFile so it can be
+// passed directly to wslconfig.WSLConfig.SaveWith.
+func writeWindowsConfigFile(path string, data []byte, perm os.FileMode) error {
+	encoder := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	encoded, err := encoder.Bytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s as UTF-16: %v", path, err)
+	}
+
+	return os.WriteFile(path, encoded, perm)
+}
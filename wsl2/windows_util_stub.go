@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// IsWSLInstalled is only meaningful on the Windows side; from within WSL we
+// already know WSL is installed (we're running inside it).
+func (w *WSL2Automator) IsWSLInstalled() bool {
+	return true
+}
+
+// syncBeforeShutdown is a Windows-only operation; phase2WindowsTasks (the
+// only caller) never runs on this side.
+func (w *WSL2Automator) syncBeforeShutdown() {}
+
+// IsWSLFeatureEnabled is only meaningful on the Windows side.
+func (w *WSL2Automator) IsWSLFeatureEnabled() bool {
+	return true
+}
+
+// enableWSLFeatures is a Windows-only operation.
+func (w *WSL2Automator) enableWSLFeatures() (bool, error) {
+	return false, fmt.Errorf("enabling Windows optional features is not supported outside of Windows")
+}
+
+// writeWindowsConfigFile is a Windows-only operation; non-Windows callers
+// should use os.WriteFile directly.
+func writeWindowsConfigFile(path string, data []byte, perm os.FileMode) error {
+	return fmt.Errorf("writeWindowsConfigFile is only supported on Windows")
+}
@@ -10,15 +10,11 @@ import (
 	"runtime"
 	"strings"
 	"time"
-)
 
-// Colors for output
-const (
-	ColorReset  = "\033[0m"
-	ColorRed    = "\033[31m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
+	"github.com/x86txt/tipsntricks/wsl2/boottest"
+	"github.com/x86txt/tipsntricks/wsl2/builder"
+	"github.com/x86txt/tipsntricks/wsl2/logging"
+	"github.com/x86txt/tipsntricks/wsl2/wslconfig"
 )
 
 // AutomationState represents the state passed between phases
@@ -27,6 +23,7 @@ type AutomationState struct {
 	KernelBuilt     bool   `json:"kernel_built"`
 	WindowsUser     string `json:"windows_user"`
 	Timestamp       int64  `json:"timestamp"`
+	CorrelationID   string `json:"correlation_id"`
 }
 
 // WSL2Automator handles the two-phase automation
@@ -40,16 +37,78 @@ type WSL2Automator struct {
 	KernelRepo   string
 	KernelDir    string
 	AutoClone    bool
+	Builder      builder.Builder
+	BuildTimeout time.Duration
+	SmokeTest    SmokeTestOptions
+	WSLConfig    WSLConfigOverrides
+	Logger       logging.Interface
+}
+
+// SmokeTestOptions controls the optional QEMU boot test run against a freshly
+// built bzImage before it is copied to KernelDest.
+type SmokeTestOptions struct {
+	Enabled  bool
+	MemoryMB int
+	CPUs     int
+	KASLR    bool
+	Timeout  time.Duration
+}
+
+// WSLConfigOverrides are .wslconfig [wsl2] keys to merge in on top of
+// whatever is already there. An empty string means "leave this key alone".
+type WSLConfigOverrides struct {
+	Memory         string
+	Processors     string
+	Swap           string
+	NetworkingMode string
+	DNSTunneling   string
+}
+
+// Options are the settings NewWSL2Automator needs to build an automator,
+// mirroring the CLI flags in main.
+type Options struct {
+	KernelBranch string
+	KernelDest   string
+	KernelRepo   string
+	KernelDir    string
+	AutoClone    bool
+	BuilderName  string
+	BuilderImage string
+	BuildTimeout time.Duration
+	SmokeTest    SmokeTestOptions
+	WSLConfig    WSLConfigOverrides
+	LogFormat    string
+	LogLevel     string
+	LogFile      string
 }
 
 // NewWSL2Automator creates a new automator instance
-func NewWSL2Automator(kernelBranch, kernelDest, kernelRepo, kernelDir string, autoClone bool) *WSL2Automator {
+func NewWSL2Automator(opts Options) (*WSL2Automator, error) {
+	b, err := builder.New(opts.BuilderName, opts.BuilderImage)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := logging.New(logging.Options{
+		Format:   opts.LogFormat,
+		Level:    opts.LogLevel,
+		FilePath: opts.LogFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	automator := &WSL2Automator{
-		KernelBranch: kernelBranch,
-		KernelDest:   kernelDest,
-		KernelRepo:   kernelRepo,
-		KernelDir:    kernelDir,
-		AutoClone:    autoClone,
+		KernelBranch: opts.KernelBranch,
+		KernelDest:   opts.KernelDest,
+		KernelRepo:   opts.KernelRepo,
+		KernelDir:    opts.KernelDir,
+		AutoClone:    opts.AutoClone,
+		Builder:      b,
+		BuildTimeout: opts.BuildTimeout,
+		SmokeTest:    opts.SmokeTest,
+		WSLConfig:    opts.WSLConfig,
+		Logger:       logger,
 	}
 	automator.IsWSL = automator.checkWSL()
 	automator.WindowsUser = automator.getWindowsUser()
@@ -61,10 +120,13 @@ func NewWSL2Automator(kernelBranch, kernelDest, kernelRepo, kernelDir string, au
 	}
 
 	automator.StateFile = filepath.Join(automator.TempDir, "automation_state.json")
-	return automator
+	return automator, nil
 }
 
-// checkWSL determines if running in WSL environment
+// checkWSL determines whether this process is currently running inside a
+// WSL distro. That's a different question from IsWSLInstalled (is WSL
+// registered on this Windows machine), which only the Windows side can
+// answer via the WSL API - /proc/version remains the right signal here.
 func (w *WSL2Automator) checkWSL() bool {
 	if runtime.GOOS != "linux" {
 		return false
@@ -78,7 +140,10 @@ func (w *WSL2Automator) checkWSL() bool {
 	return strings.Contains(strings.ToLower(string(data)), "microsoft")
 }
 
-// getWindowsUser retrieves the Windows username
+// getWindowsUser retrieves the Windows username. wslapi.dll is a Windows PE
+// DLL and can't be loaded from this Linux process, so from inside WSL the
+// only bridge across the VM boundary is interop (launching a Windows
+// executable) - there is no Win32 API call this can be replaced with.
 func (w *WSL2Automator) getWindowsUser() string {
 	if w.IsWSL {
 		cmd := exec.Command("cmd.exe", "/c", "echo %USERNAME%")
@@ -91,24 +156,6 @@ func (w *WSL2Automator) getWindowsUser() string {
 	return os.Getenv("USERNAME")
 }
 
-// log prints colored log messages
-func (w *WSL2Automator) log(message, level string) {
-	colors := map[string]string{
-		"INFO":    ColorBlue,
-		"SUCCESS": ColorGreen,
-		"WARNING": ColorYellow,
-		"ERROR":   ColorRed,
-	}
-
-	color := colors[level]
-	if color == "" {
-		color = ColorBlue
-	}
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Printf("%s%s [%s]%s %s\n", color, timestamp, level, ColorReset, message)
-}
-
 // saveState saves automation state to file
 func (w *WSL2Automator) saveState(state AutomationState) error {
 	err := os.MkdirAll(w.TempDir, 0755)
@@ -137,31 +184,63 @@ func (w *WSL2Automator) loadState() (AutomationState, error) {
 	return state, err
 }
 
-// runCommand executes a command with logging
+// exitCode returns cmd's exit code, or -1 if the process never started.
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// runCommand executes a command, logging its invocation, duration, and exit
+// code.
 func (w *WSL2Automator) runCommand(name string, args ...string) error {
-	w.log(fmt.Sprintf("Running: %s %s", name, strings.Join(args, " ")), "INFO")
+	w.Logger.Debug("running command", logging.F("command", name), logging.F("args", args))
+	start := time.Now()
+
 	cmd := exec.Command(name, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	err := cmd.Run()
+
+	w.Logger.Info(fmt.Sprintf("Running: %s %s", name, strings.Join(args, " ")),
+		logging.F("duration_ms", time.Since(start).Milliseconds()),
+		logging.F("exit_code", exitCode(cmd)))
+
+	return err
 }
 
-// runCommandOutput executes a command and returns output
+// runCommandOutput is like runCommand but captures and returns stdout, also
+// logging it (and stderr) at debug level.
 func (w *WSL2Automator) runCommandOutput(name string, args ...string) (string, error) {
-	w.log(fmt.Sprintf("Running: %s %s", name, strings.Join(args, " ")), "INFO")
+	w.Logger.Debug("running command", logging.F("command", name), logging.F("args", args))
+	start := time.Now()
+
 	cmd := exec.Command(name, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
+
+	w.Logger.Info(fmt.Sprintf("Running: %s %s", name, strings.Join(args, " ")),
+		logging.F("duration_ms", time.Since(start).Milliseconds()),
+		logging.F("exit_code", exitCode(cmd)))
+	w.Logger.Debug("command output",
+		logging.F("stdout", string(output)),
+		logging.F("stderr", stderr.String()))
+
 	return strings.TrimSpace(string(output)), err
 }
 
 // phase1WSLTasks performs tasks inside WSL2
 func (w *WSL2Automator) phase1WSLTasks() error {
 	if !w.IsWSL {
-		w.log("Phase 1 must run inside WSL2", "ERROR")
+		w.Logger.Error("Phase 1 must run inside WSL2")
 		return fmt.Errorf("not running in WSL2")
 	}
 
-	w.log("Starting Phase 1: WSL2 tasks", "INFO")
+	correlationID := fmt.Sprintf("phase1-%d-%d", time.Now().UnixNano(), os.Getpid())
+	w.Logger = w.Logger.WithCorrelationID(correlationID)
+	w.Logger.Info("Starting Phase 1: WSL2 tasks")
 
 	// Save current directory
 	originalDir, err := os.Getwd()
@@ -171,14 +250,14 @@ func (w *WSL2Automator) phase1WSLTasks() error {
 	// Ensure we return to original directory
 	defer func() {
 		if err := os.Chdir(originalDir); err != nil {
-			w.log(fmt.Sprintf("Failed to return to original directory: %v", err), "WARNING")
+			w.Logger.Warn(fmt.Sprintf("Failed to return to original directory: %v", err))
 		}
 	}()
 
 	// Check if kernel directory exists
 	if _, err := os.Stat(w.KernelDir); os.IsNotExist(err) {
 		if w.AutoClone {
-			w.log(fmt.Sprintf("Cloning kernel repository from %s...", w.KernelRepo), "INFO")
+			w.Logger.Info(fmt.Sprintf("Cloning kernel repository from %s...", w.KernelRepo))
 			err := w.runCommand("git", "clone",
 				w.KernelRepo, w.KernelDir,
 				"--depth=1", "-b", w.KernelBranch)
@@ -186,64 +265,55 @@ func (w *WSL2Automator) phase1WSLTasks() error {
 				return fmt.Errorf("failed to clone kernel: %v", err)
 			}
 		} else {
-			w.log(fmt.Sprintf("Kernel directory '%s' not found!", w.KernelDir), "ERROR")
-			w.log("Please clone the repository manually or set --auto-clone", "ERROR")
+			w.Logger.Error(fmt.Sprintf("Kernel directory '%s' not found!", w.KernelDir))
+			w.Logger.Error("Please clone the repository manually or set --auto-clone")
 			return fmt.Errorf("kernel directory not found and auto-clone disabled")
 		}
 	} else {
-		w.log(fmt.Sprintf("Using existing kernel directory: %s", w.KernelDir), "INFO")
-	}
-
-	// Change to kernel directory
-	err = os.Chdir(w.KernelDir)
-	if err != nil {
-		return fmt.Errorf("failed to change directory: %v", err)
+		w.Logger.Info(fmt.Sprintf("Using existing kernel directory: %s", w.KernelDir))
 	}
 
-	// Install dependencies
-	w.log("Installing build dependencies...", "INFO")
-	err = w.runCommand("sudo", "apt", "update")
+	kernelDirAbs, err := filepath.Abs(w.KernelDir)
 	if err != nil {
-		return fmt.Errorf("failed to update packages: %v", err)
+		return fmt.Errorf("failed to resolve kernel directory: %v", err)
 	}
 
-	err = w.runCommand("sudo", "apt", "install", "-y",
-		"build-essential", "flex", "bison", "libssl-dev",
-		"libelf-dev", "bc", "python3", "pahole", "cpio")
-	if err != nil {
-		return fmt.Errorf("failed to install dependencies: %v", err)
-	}
-
-	// Build kernel
-	w.log("Building kernel...", "INFO")
 	nproc, err := w.runCommandOutput("nproc")
-	if err != nil {
-		nproc = "4" // fallback
-	}
-
-	err = w.runCommand("make", fmt.Sprintf("-j%s", nproc), "KCONFIG_CONFIG=Microsoft/config-wsl")
+	jobs := 4
+	if err == nil {
+		fmt.Sscanf(nproc, "%d", &jobs)
+	}
+
+	w.Logger.Info(fmt.Sprintf("Building kernel with %T...", w.Builder))
+	bzImagePath, modulesTar, headersTar, err := w.Builder.Build(builder.BuildParams{
+		KernelDir:  kernelDirAbs,
+		ConfigPath: "Microsoft/config-wsl",
+		Jobs:       jobs,
+		OutputDir:  w.TempDir,
+		Timeout:    w.BuildTimeout,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to build kernel: %v", err)
 	}
+	if modulesTar != "" {
+		w.Logger.Info(fmt.Sprintf("Kernel modules packaged at %s", modulesTar))
+	}
+	if headersTar != "" {
+		w.Logger.Info(fmt.Sprintf("Kernel headers packaged at %s", headersTar))
+	}
 
-	// Install modules
-	w.log("Installing kernel modules...", "INFO")
-	err = w.runCommand("sudo", "make", "modules_install", "headers_install")
-	if err != nil {
-		return fmt.Errorf("failed to install modules: %v", err)
+	if w.SmokeTest.Enabled {
+		if err := w.smokeTestKernel(bzImagePath); err != nil {
+			return err
+		}
 	}
 
 	// Copy kernel to Windows
-	kernelPath := "arch/x86/boot/bzImage"
-	if _, err := os.Stat(kernelPath); err == nil {
-		err = w.runCommand("cp", kernelPath, w.KernelDest)
-		if err != nil {
-			return fmt.Errorf("failed to copy kernel: %v", err)
-		}
-		w.log(fmt.Sprintf("Kernel copied to %s", w.KernelDest), "SUCCESS")
-	} else {
-		return fmt.Errorf("kernel image not found at %s - build may have failed", kernelPath)
+	err = w.runCommand("cp", bzImagePath, w.KernelDest)
+	if err != nil {
+		return fmt.Errorf("failed to copy kernel: %v", err)
 	}
+	w.Logger.Info(fmt.Sprintf("Kernel copied to %s", w.KernelDest), logging.F("outcome", "success"))
 
 	// Save state for Phase 2
 	state := AutomationState{
@@ -251,6 +321,7 @@ func (w *WSL2Automator) phase1WSLTasks() error {
 		KernelBuilt:     true,
 		WindowsUser:     w.WindowsUser,
 		Timestamp:       time.Now().Unix(),
+		CorrelationID:   correlationID,
 	}
 
 	err = w.saveState(state)
@@ -258,131 +329,172 @@ func (w *WSL2Automator) phase1WSLTasks() error {
 		return fmt.Errorf("failed to save state: %v", err)
 	}
 
-	// Create Phase 2 script
-	err = w.createPhase2Script()
+	w.Logger.Info("Phase 1 completed successfully", logging.F("outcome", "success"))
+	w.announcePhase2()
+
+	return nil
+}
+
+// smokeTestKernel boots bzImagePath under QEMU and refuses to let the build
+// proceed if it fails to reach userspace or panics, so a broken kernel never
+// gets deployed to KernelDest.
+func (w *WSL2Automator) smokeTestKernel(bzImagePath string) error {
+	w.Logger.Info("Running smoke-test boot under QEMU...")
+
+	initramfsPath, err := boottest.EnsureInitramfs(w.TempDir)
 	if err != nil {
-		return fmt.Errorf("failed to create phase 2 script: %v", err)
+		return fmt.Errorf("failed to prepare smoke-test initramfs: %v", err)
 	}
 
-	w.log("Phase 1 completed successfully", "SUCCESS")
-	w.log("Starting Phase 2 on Windows...", "INFO")
+	result, err := boottest.Run(boottest.Config{
+		KernelPath:    bzImagePath,
+		InitramfsPath: initramfsPath,
+		MemoryMB:      w.SmokeTest.MemoryMB,
+		CPUs:          w.SmokeTest.CPUs,
+		KASLR:         w.SmokeTest.KASLR,
+		Timeout:       w.SmokeTest.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run smoke test: %v", err)
+	}
 
-	// Trigger Phase 2
-	w.triggerPhase2()
+	if !result.Passed {
+		w.Logger.Error(fmt.Sprintf("Smoke test FAILED: %s", result.Reason))
+		w.Logger.Error(result.SerialLog)
+		return fmt.Errorf("smoke test failed: %s", result.Reason)
+	}
 
+	w.Logger.Info(fmt.Sprintf("Smoke test PASSED in %dms: %s", result.BootedInMS, result.Reason), logging.F("outcome", "success"))
 	return nil
 }
 
-// createPhase2Script creates the PowerShell script for Phase 2
-func (w *WSL2Automator) createPhase2Script() error {
-	if w.WindowsUser == "" {
-		w.log("Cannot determine Windows user", "WARNING")
-		return nil
-	}
-
-	psScript := fmt.Sprintf(`# Phase 2: Windows tasks
-$ErrorActionPreference = "Stop"
-
-Write-Host "Starting Phase 2: Windows tasks" -ForegroundColor Blue
-
-try {
-    # Create .wslconfig
-    $wslConfigPath = "C:\Users\%s\.wslconfig"
-    $wslConfig = @"
-[wsl2]
-kernel=%s
-"@
-    
-    Write-Host "Creating WSL config at $wslConfigPath" -ForegroundColor Green
-    $wslConfig | Out-File -FilePath $wslConfigPath -Encoding UTF8
-    
-    # Wait a moment
-    Start-Sleep -Seconds 2
-    
-    # Shutdown WSL
-    Write-Host "Shutting down WSL..." -ForegroundColor Yellow
-    wsl --shutdown
-    
-    # Wait for shutdown
-    Start-Sleep -Seconds 5
-    
-    # Success message
-    Write-Host "WSL automation completed successfully!" -ForegroundColor Green
-    Write-Host "WSL will use the new kernel on next startup." -ForegroundColor Green
-    
-} catch {
-    Write-Host "Phase 2 failed: $_" -ForegroundColor Red
-    exit 1
+// announcePhase2 tells the user how to finish the install. Phase 1 used to
+// write out and run a hand-rolled PowerShell script that re-implemented a
+// subset of the .wslconfig merge (kernel= only, none of the --memory/
+// --processors/--swap/--networking-mode/--dns-tunneling overrides) and shut
+// WSL down via powershell.exe. That duplicated - incompletely - the
+// wslconfig-based merge phase2WindowsTasks already does, so Phase 2 is now
+// the only place .wslconfig gets touched; Phase 1 just points at it.
+func (w *WSL2Automator) announcePhase2() {
+	w.Logger.Info("Next: Phase 2 on Windows")
+	cmd := w.phase2Invocation()
+	w.Logger.Warn(fmt.Sprintf("Phase 2 must be run from Windows: build this tool there and run %s", cmd))
 }
 
-# Cleanup
-Remove-Item "C:\temp\wsl2_automation\phase2.ps1" -ErrorAction SilentlyContinue
-`, w.WindowsUser, w.KernelDest)
+// phase2Invocation renders the exact command-line Phase 2 needs, including
+// every .wslconfig override the user passed to Phase 1, so none of them get
+// silently dropped on the way to the Windows side.
+func (w *WSL2Automator) phase2Invocation() string {
+	args := []string{"wsl2.exe", "-phase", "2", "-kernel-dest", quoteArg(w.KernelDest)}
 
-	// Create directory on Windows
-	psScriptDir := "/mnt/c/temp/wsl2_automation"
-	err := os.MkdirAll(psScriptDir, 0755)
-	if err != nil {
-		return err
+	if w.WSLConfig.Memory != "" {
+		args = append(args, "-memory", quoteArg(w.WSLConfig.Memory))
 	}
-
-	// Write PowerShell script
-	psScriptPath := filepath.Join(psScriptDir, "phase2.ps1")
-	err = os.WriteFile(psScriptPath, []byte(psScript), 0644)
-	if err != nil {
-		return err
+	if w.WSLConfig.Processors != "" {
+		args = append(args, "-processors", quoteArg(w.WSLConfig.Processors))
+	}
+	if w.WSLConfig.Swap != "" {
+		args = append(args, "-swap", quoteArg(w.WSLConfig.Swap))
+	}
+	if w.WSLConfig.NetworkingMode != "" {
+		args = append(args, "-networking-mode", quoteArg(w.WSLConfig.NetworkingMode))
+	}
+	if w.WSLConfig.DNSTunneling != "" {
+		args = append(args, "-dns-tunneling", quoteArg(w.WSLConfig.DNSTunneling))
 	}
 
-	w.log("Phase 2 script created on Windows", "SUCCESS")
-	return nil
+	return strings.Join(args, " ")
 }
 
-// triggerPhase2 executes the Phase 2 script on Windows
-func (w *WSL2Automator) triggerPhase2() {
-	// Execute PowerShell script on Windows
-	cmd := exec.Command("powershell.exe", "-ExecutionPolicy", "Bypass",
-		"-File", "C:\\temp\\wsl2_automation\\phase2.ps1")
-
-	// This is expected to fail as WSL will be shut down
-	cmd.Run()
-	w.log("Phase 2 triggered (WSL shutdown expected)", "SUCCESS")
+// quoteArg wraps arg in double quotes if it contains whitespace, so a
+// printed command line (e.g. a --kernel-dest under "C:\Program Files\...")
+// can be copy-pasted as a single argument rather than splitting on the space.
+func quoteArg(arg string) string {
+	if strings.ContainsAny(arg, " \t") {
+		return fmt.Sprintf("%q", arg)
+	}
+	return arg
 }
 
 // phase2WindowsTasks performs tasks on Windows
 func (w *WSL2Automator) phase2WindowsTasks() error {
 	if w.IsWSL {
-		w.log("Phase 2 should run on Windows, not WSL", "ERROR")
+		w.Logger.Error("Phase 2 should run on Windows, not WSL")
 		return fmt.Errorf("running in WSL2")
 	}
 
-	w.log("Starting Phase 2: Windows tasks", "INFO")
+	w.Logger.Info("Starting Phase 2: Windows tasks")
+
+	if !w.IsWSLInstalled() {
+		w.Logger.Error("WSL is not installed on this machine")
+		return fmt.Errorf("wsl is not installed")
+	}
 
 	// Load state
 	state, err := w.loadState()
 	if err != nil || !state.Phase1Completed {
-		w.log("Phase 1 not completed", "ERROR")
+		w.Logger.Error("Phase 1 not completed")
 		return fmt.Errorf("phase 1 not completed")
 	}
 
-	// Create .wslconfig
-	wslConfigPath := fmt.Sprintf("C:/Users/%s/.wslconfig", w.WindowsUser)
-	wslConfigContent := fmt.Sprintf("[wsl2]\nkernel=%s\n", w.KernelDest)
+	if state.CorrelationID != "" {
+		w.Logger = w.Logger.WithCorrelationID(state.CorrelationID)
+		w.Logger.Info("Resuming Phase 1's correlation ID for Phase 2 logs")
+	}
 
-	err = os.WriteFile(wslConfigPath, []byte(wslConfigContent), 0644)
+	if !w.IsWSLFeatureEnabled() {
+		w.Logger.Warn("WSL optional features are not enabled, enabling now...")
+		rebootRequired, err := w.enableWSLFeatures()
+		if err != nil {
+			return fmt.Errorf("failed to enable WSL features: %v", err)
+		}
+		if rebootRequired {
+			w.Logger.Warn("WSL features enabled but a reboot is required before continuing")
+			return fmt.Errorf("reboot required to finish enabling WSL features")
+		}
+	}
+
+	// Merge the new kernel path (and any requested overrides) into .wslconfig
+	// in place, rather than overwriting the whole file.
+	wslConfigPath := fmt.Sprintf("C:/Users/%s/.wslconfig", w.WindowsUser)
+	cfg, err := wslconfig.Load(wslConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed to create .wslconfig: %v", err)
+		return fmt.Errorf("failed to load .wslconfig: %v", err)
+	}
+
+	cfg.SetKernel(w.KernelDest)
+	if w.WSLConfig.Memory != "" {
+		cfg.SetMemory(w.WSLConfig.Memory)
+	}
+	if w.WSLConfig.Processors != "" {
+		cfg.SetProcessors(w.WSLConfig.Processors)
+	}
+	if w.WSLConfig.Swap != "" {
+		cfg.SetSwap(w.WSLConfig.Swap)
+	}
+	if w.WSLConfig.NetworkingMode != "" {
+		cfg.SetNetworkingMode(w.WSLConfig.NetworkingMode)
+	}
+	if w.WSLConfig.DNSTunneling != "" {
+		cfg.SetDNSTunneling(w.WSLConfig.DNSTunneling == "true")
+	}
+
+	if err := cfg.SaveWith(writeWindowsConfigFile); err != nil {
+		return fmt.Errorf("failed to save .wslconfig: %v", err)
 	}
 
-	w.log(fmt.Sprintf("WSL config created at %s", wslConfigPath), "SUCCESS")
+	w.Logger.Info(fmt.Sprintf("WSL config updated at %s", wslConfigPath), logging.F("outcome", "success"))
+
+	w.syncBeforeShutdown()
 
 	// Shutdown WSL
-	w.log("Shutting down WSL...", "INFO")
+	w.Logger.Info("Shutting down WSL...")
 	err = w.runCommand("wsl", "--shutdown")
 	if err != nil {
 		return fmt.Errorf("failed to shutdown WSL: %v", err)
 	}
 
-	w.log("Phase 2 completed successfully", "SUCCESS")
+	w.Logger.Info("Phase 2 completed successfully", logging.F("outcome", "success"))
 	return nil
 }
 
@@ -390,14 +502,30 @@ func (w *WSL2Automator) phase2WindowsTasks() error {
 func (w *WSL2Automator) cleanup() error {
 	err := os.RemoveAll(w.TempDir)
 	if err != nil {
-		w.log(fmt.Sprintf("Cleanup failed: %v", err), "WARNING")
+		w.Logger.Warn(fmt.Sprintf("Cleanup failed: %v", err))
 		return err
 	}
-	w.log("Cleanup completed", "SUCCESS")
+	w.Logger.Info("Cleanup completed", logging.F("outcome", "success"))
 	return nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "matrix" {
+		if err := runMatrixCLI(os.Args[2:]); err != nil {
+			fmt.Printf("Build matrix failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rollback" {
+		if err := runRollbackCLI(os.Args[2:]); err != nil {
+			fmt.Printf("Rollback failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var phase = flag.String("phase", "1", "Which phase to run (1 or 2)")
 	var cleanup = flag.Bool("cleanup", false, "Clean up temporary files")
 	var kernelBranch = flag.String("kernel-branch", "linux-msft-wsl-6.6.y", "Kernel branch to build")
@@ -405,16 +533,61 @@ func main() {
 	var kernelRepo = flag.String("kernel-repo", "https://github.com/microsoft/WSL2-Linux-Kernel.git", "Kernel repository URL")
 	var kernelDir = flag.String("kernel-dir", "WSL2-Linux-Kernel", "Local kernel directory name")
 	var noClone = flag.Bool("no-clone", false, "Don't auto-clone the repository")
+	var builderName = flag.String("builder", "host", "Where to run the kernel build: host, docker, or podman")
+	var builderImage = flag.String("builder-image", "", "Container image to build in (required for docker/podman)")
+	var buildTimeout = flag.Duration("build-timeout", 0, "Kill the build if it runs longer than this (0 = no timeout)")
+	var smokeTest = flag.Bool("smoke-test", false, "Boot the built kernel under QEMU before copying it to KernelDest")
+	var smokeTestMemoryMB = flag.Int("smoke-test-memory", 512, "Memory (MB) to give the smoke-test VM")
+	var smokeTestCPUs = flag.Int("smoke-test-cpus", 1, "CPUs to give the smoke-test VM")
+	var smokeTestKASLR = flag.String("kaslr", "off", "KASLR setting for the smoke-test VM: on or off")
+	var smokeTestTimeout = flag.Duration("smoke-test-timeout", 60*time.Second, "How long to wait for the boot marker before failing the smoke test")
+	var memory = flag.String("memory", "", "Merge a memory= override into .wslconfig (e.g. 8GB)")
+	var processors = flag.String("processors", "", "Merge a processors= override into .wslconfig")
+	var swap = flag.String("swap", "", "Merge a swap= override into .wslconfig (e.g. 2GB)")
+	var networkingMode = flag.String("networking-mode", "", "Merge a networkingMode= override into .wslconfig (e.g. mirrored)")
+	var dnsTunneling = flag.String("dns-tunneling", "", "Merge a dnsTunneling= override into .wslconfig (true or false)")
+	var logFormat = flag.String("log-format", "text", "Log output format: text or json")
+	var logLevel = flag.String("log-level", "info", "Log level: trace, debug, info, warn, or error")
+	var logFile = flag.String("log-file", "", "Additionally tee NDJSON log events to this file")
 	flag.Parse()
 
-	automator := NewWSL2Automator(*kernelBranch, *kernelDest, *kernelRepo, *kernelDir, !*noClone)
+	automator, err := NewWSL2Automator(Options{
+		KernelBranch: *kernelBranch,
+		KernelDest:   *kernelDest,
+		KernelRepo:   *kernelRepo,
+		KernelDir:    *kernelDir,
+		AutoClone:    !*noClone,
+		BuilderName:  *builderName,
+		BuilderImage: *builderImage,
+		BuildTimeout: *buildTimeout,
+		SmokeTest: SmokeTestOptions{
+			Enabled:  *smokeTest,
+			MemoryMB: *smokeTestMemoryMB,
+			CPUs:     *smokeTestCPUs,
+			KASLR:    *smokeTestKASLR != "off",
+			Timeout:  *smokeTestTimeout,
+		},
+		WSLConfig: WSLConfigOverrides{
+			Memory:         *memory,
+			Processors:     *processors,
+			Swap:           *swap,
+			NetworkingMode: *networkingMode,
+			DNSTunneling:   *dnsTunneling,
+		},
+		LogFormat: *logFormat,
+		LogLevel:  *logLevel,
+		LogFile:   *logFile,
+	})
+	if err != nil {
+		fmt.Printf("Failed to initialize: %v\n", err)
+		os.Exit(1)
+	}
 
 	if *cleanup {
 		automator.cleanup()
 		return
 	}
 
-	var err error
 	switch *phase {
 	case "1":
 		err = automator.phase1WSLTasks()
@@ -426,7 +599,7 @@ func main() {
 	}
 
 	if err != nil {
-		automator.log(fmt.Sprintf("Automation failed: %v", err), "ERROR")
+		automator.Logger.Error(fmt.Sprintf("Automation failed: %v", err))
 		os.Exit(1)
 	}
 }
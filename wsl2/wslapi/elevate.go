@@ -0,0 +1,95 @@
+//go:build windows
+
+package wslapi
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	seeMaskNoCloseProcess = 0x00000040
+	swNormal              = 1
+)
+
+// shellExecuteInfo mirrors the Win32 SHELLEXECUTEINFOW structure field for
+// field; RunElevated only sets the fields a "runas" elevation request needs.
+type shellExecuteInfo struct {
+	cbSize         uint32
+	fMask          uint32
+	hwnd           uintptr
+	lpVerb         *uint16
+	lpFile         *uint16
+	lpParameters   *uint16
+	lpDirectory    *uint16
+	nShow          int32
+	hInstApp       uintptr
+	lpIDList       uintptr
+	lpClass        *uint16
+	hkeyClass      uintptr
+	dwHotKey       uint32
+	hIconOrMonitor uintptr
+	hProcess       syscall.Handle
+}
+
+var (
+	shell32             = syscall.NewLazyDLL("shell32.dll")
+	procShellExecuteExW = shell32.NewProc("ShellExecuteExW")
+)
+
+// RunElevated launches file with args under an elevated ("runas") token and
+// waits for it to exit, returning the process exit code. It is used to run
+// DISM or other admin-only commands from an otherwise unprivileged process.
+func RunElevated(file, args string) (uint32, error) {
+	filePtr, err := syscall.UTF16PtrFromString(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode file: %v", err)
+	}
+
+	argsPtr, err := syscall.UTF16PtrFromString(args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode args: %v", err)
+	}
+
+	verbPtr, err := syscall.UTF16PtrFromString("runas")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode verb: %v", err)
+	}
+
+	info := shellExecuteInfo{
+		fMask:        seeMaskNoCloseProcess,
+		lpVerb:       verbPtr,
+		lpFile:       filePtr,
+		lpParameters: argsPtr,
+		nShow:        swNormal,
+	}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+
+	ret, _, callErr := procShellExecuteExW.Call(uintptr(unsafe.Pointer(&info)))
+	if ret == 0 {
+		return 0, fmt.Errorf("ShellExecuteEx failed: %v", callErr)
+	}
+	defer syscall.CloseHandle(info.hProcess)
+
+	if _, err := syscall.WaitForSingleObject(info.hProcess, syscall.INFINITE); err != nil {
+		return 0, fmt.Errorf("failed waiting for elevated process: %v", err)
+	}
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(info.hProcess, &exitCode); err != nil {
+		return 0, fmt.Errorf("failed to read elevated process exit code: %v", err)
+	}
+
+	return exitCode, nil
+}
+
+// EnableOptionalFeature enables a Windows optional feature (e.g.
+// "Microsoft-Windows-Subsystem-Linux" or "VirtualMachinePlatform") via an
+// elevated DISM invocation. The returned exit code should be checked against
+// ErrorSuccessRebootInitiated and ErrorSuccessRebootRequired, since DISM
+// reports those as success rather than failure.
+func EnableOptionalFeature(featureName string) (uint32, error) {
+	args := fmt.Sprintf("/online /enable-feature /featurename:%s /all /norestart", featureName)
+	return RunElevated("dism.exe", args)
+}
@@ -0,0 +1,138 @@
+//go:build windows
+
+// Package wslapi provides thin bindings around the Windows WSL API
+// (wslapi.dll / api-ms-win-wsl-api-l1-1-0.dll) so callers can query and
+// drive WSL distributions without shelling out to wsl.exe.
+package wslapi
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Well-known Win32 exit/status codes returned by feature-enablement flows.
+const (
+	ErrorSuccessRebootInitiated = 1641
+	ErrorSuccessRebootRequired  = 3010
+)
+
+var (
+	wslDLL = syscall.NewLazyDLL("wslapi.dll")
+
+	procWslIsDistributionRegistered = wslDLL.NewProc("WslIsDistributionRegistered")
+	procWslLaunch                   = wslDLL.NewProc("WslLaunch")
+	procWslConfigureDistribution    = wslDLL.NewProc("WslConfigureDistribution")
+	procWslRegisterDistribution     = wslDLL.NewProc("WslRegisterDistribution")
+)
+
+// Available reports whether wslapi.dll can be loaded on this machine. Every
+// other function in this package calls through a LazyDLL, which panics on
+// Call if the DLL isn't found, so callers that need to detect "WSL isn't
+// installed" rather than crash should check this first.
+func Available() bool {
+	return wslDLL.Load() == nil
+}
+
+// IsDistributionRegistered reports whether distributionName is registered
+// with WSL (WslIsDistributionRegistered).
+func IsDistributionRegistered(distributionName string) (bool, error) {
+	namePtr, err := syscall.UTF16PtrFromString(distributionName)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode distribution name: %v", err)
+	}
+
+	ret, _, _ := procWslIsDistributionRegistered.Call(uintptr(unsafe.Pointer(namePtr)))
+	return ret != 0, nil
+}
+
+// RegisterDistribution registers a distribution from a tarball
+// (WslRegisterDistribution).
+func RegisterDistribution(distributionName, tarGzFilename string) error {
+	namePtr, err := syscall.UTF16PtrFromString(distributionName)
+	if err != nil {
+		return fmt.Errorf("failed to encode distribution name: %v", err)
+	}
+
+	tarPtr, err := syscall.UTF16PtrFromString(tarGzFilename)
+	if err != nil {
+		return fmt.Errorf("failed to encode tarball path: %v", err)
+	}
+
+	hr, _, _ := procWslRegisterDistribution.Call(
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(tarPtr)),
+	)
+	if hr != 0 {
+		return fmt.Errorf("WslRegisterDistribution failed: HRESULT 0x%x", uint32(hr))
+	}
+	return nil
+}
+
+// ConfigureDistribution sets the default UID and WSL flags for a
+// distribution (WslConfigureDistribution).
+func ConfigureDistribution(distributionName string, defaultUID uint32, wslDistributionFlags uint32) error {
+	namePtr, err := syscall.UTF16PtrFromString(distributionName)
+	if err != nil {
+		return fmt.Errorf("failed to encode distribution name: %v", err)
+	}
+
+	hr, _, _ := procWslConfigureDistribution.Call(
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(defaultUID),
+		uintptr(wslDistributionFlags),
+	)
+	if hr != 0 {
+		return fmt.Errorf("WslConfigureDistribution failed: HRESULT 0x%x", uint32(hr))
+	}
+	return nil
+}
+
+// Launch runs command inside distributionName (WslLaunch) and returns the
+// process exit code.
+func Launch(distributionName, command string, useCurrentWorkingDirectory bool) (uint32, error) {
+	namePtr, err := syscall.UTF16PtrFromString(distributionName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode distribution name: %v", err)
+	}
+
+	cmdPtr, err := syscall.UTF16PtrFromString(command)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode command: %v", err)
+	}
+
+	var useCwd uintptr
+	if useCurrentWorkingDirectory {
+		useCwd = 1
+	}
+
+	stdin := syscall.Stdin
+	stdout := syscall.Stdout
+	stderr := syscall.Stderr
+
+	var processHandle syscall.Handle
+	hr, _, _ := procWslLaunch.Call(
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(cmdPtr)),
+		useCwd,
+		uintptr(stdin),
+		uintptr(stdout),
+		uintptr(stderr),
+		uintptr(unsafe.Pointer(&processHandle)),
+	)
+	if hr != 0 {
+		return 0, fmt.Errorf("WslLaunch failed: HRESULT 0x%x", uint32(hr))
+	}
+
+	event, err := syscall.WaitForSingleObject(processHandle, syscall.INFINITE)
+	if err != nil || event != 0 {
+		return 0, fmt.Errorf("failed waiting for WSL process: %v", err)
+	}
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(processHandle, &exitCode); err != nil {
+		return 0, fmt.Errorf("failed to read WSL process exit code: %v", err)
+	}
+
+	return exitCode, nil
+}
@@ -0,0 +1,141 @@
+package wslconfig
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// line is a single line of a .wslconfig file: either a comment/blank line
+// (key == "") or a "key=value" pair. Keeping the raw text around lets
+// document.render reproduce the text of every line we didn't touch; render
+// does end every line with "\n" (CRLF endings are not kept) and writes section
+// headers back as "[name]".
+type line struct {
+	raw   string
+	key   string
+	value string
+}
+
+// section is an ordered run of lines under one `[name]` header. The
+// top-of-file section (before any header) uses name == "".
+type section struct {
+	name  string
+	lines []line
+}
+
+// document is a line-preserving parse of an INI-like .wslconfig file: it
+// keeps comments, blank lines, and unrecognized keys exactly as written so
+// that merging in one new value doesn't discard everything else in the
+// file.
+type document struct {
+	sections []*section
+}
+
+func parseDocument(text string) (*document, error) {
+	doc := &document{}
+	current := &section{name: ""}
+	doc.sections = append(doc.sections, current)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = &section{name: strings.TrimSuffix(strings.TrimPrefix(trimmed, "["), "]")}
+			doc.sections = append(doc.sections, current)
+			continue
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			current.lines = append(current.lines, line{raw: raw})
+			continue
+		}
+
+		if idx := strings.Index(trimmed, "="); idx >= 0 {
+			key := strings.TrimSpace(trimmed[:idx])
+			value := strings.TrimSpace(trimmed[idx+1:])
+			current.lines = append(current.lines, line{raw: raw, key: key, value: value})
+			continue
+		}
+
+		// Unparseable line: keep it verbatim rather than dropping it.
+		current.lines = append(current.lines, line{raw: raw})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse wslconfig: %v", err)
+	}
+
+	return doc, nil
+}
+
+// get returns the value of key in the named section, if present.
+func (d *document) get(sectionName, key string) (string, bool) {
+	for _, s := range d.sections {
+		if s.name != sectionName {
+			continue
+		}
+		for _, l := range s.lines {
+			if l.key == key {
+				return l.value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// set updates key in-place within the named section if it already exists,
+// appends it to that section if the section exists but the key doesn't, or
+// creates the section (and the key) if neither exists yet.
+func (d *document) set(sectionName, key, value string) {
+	var target *section
+	for _, s := range d.sections {
+		if s.name == sectionName {
+			target = s
+			break
+		}
+	}
+
+	if target == nil {
+		target = &section{name: sectionName}
+		d.sections = append(d.sections, target)
+	}
+
+	for i, l := range target.lines {
+		if l.key == key {
+			target.lines[i] = line{raw: fmt.Sprintf("%s=%s", key, value), key: key, value: value}
+			return
+		}
+	}
+
+	target.lines = append(target.lines, line{raw: fmt.Sprintf("%s=%s", key, value), key: key, value: value})
+}
+
+// sectionNames returns every distinct, non-empty section name in the
+// document, in file order.
+func (d *document) sectionNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range d.sections {
+		if s.name != "" && !seen[s.name] {
+			seen[s.name] = true
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+func (d *document) render() string {
+	var b strings.Builder
+	for _, s := range d.sections {
+		if s.name != "" {
+			fmt.Fprintf(&b, "[%s]\n", s.name)
+		}
+		for _, l := range s.lines {
+			b.WriteString(l.raw)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
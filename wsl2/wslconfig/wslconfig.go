@@ -0,0 +1,217 @@
+// Package wslconfig reads and writes .wslconfig files without clobbering
+// settings it doesn't know about: comments, other [wsl2]/[experimental]
+// keys, and per-distro sections all survive a round trip.
+package wslconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WSL2Section mirrors the well-known keys under [wsl2].
+type WSL2Section struct {
+	Kernel         string
+	Memory         string
+	Processors     string
+	Swap           string
+	NetworkingMode string
+	DNSTunneling   string
+	Firewall       string
+}
+
+// ExperimentalSection mirrors the well-known keys under [experimental].
+type ExperimentalSection struct {
+	AutoMemoryReclaim string
+	SparseVHD         string
+}
+
+// DistroSection holds the keys found under a per-distro section header
+// (e.g. `[Ubuntu-22.04]`).
+type DistroSection map[string]string
+
+// WSLConfig is a typed, round-trippable view of a .wslconfig file.
+type WSLConfig struct {
+	WSL2         WSL2Section
+	Experimental ExperimentalSection
+	Distros      map[string]DistroSection
+
+	doc  *document
+	path string
+}
+
+var knownTopLevelSections = map[string]bool{
+	"wsl2":         true,
+	"experimental": true,
+	"":             true,
+}
+
+// Load parses the .wslconfig file at path. A missing file is treated as an
+// empty config so callers can use Load+Save to create one from scratch.
+func Load(path string) (*WSLConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			data = nil
+		} else {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	}
+
+	doc, err := parseDocument(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &WSLConfig{path: path, doc: doc, Distros: map[string]DistroSection{}}
+	cfg.WSL2 = WSL2Section{
+		Kernel:         getOr(doc, "wsl2", "kernel"),
+		Memory:         getOr(doc, "wsl2", "memory"),
+		Processors:     getOr(doc, "wsl2", "processors"),
+		Swap:           getOr(doc, "wsl2", "swap"),
+		NetworkingMode: getOr(doc, "wsl2", "networkingMode"),
+		DNSTunneling:   getOr(doc, "wsl2", "dnsTunneling"),
+		Firewall:       getOr(doc, "wsl2", "firewall"),
+	}
+	cfg.Experimental = ExperimentalSection{
+		AutoMemoryReclaim: getOr(doc, "experimental", "autoMemoryReclaim"),
+		SparseVHD:         getOr(doc, "experimental", "sparseVhd"),
+	}
+
+	for _, name := range doc.sectionNames() {
+		if knownTopLevelSections[name] {
+			continue
+		}
+		distro := DistroSection{}
+		for _, s := range doc.sections {
+			if s.name != name {
+				continue
+			}
+			for _, l := range s.lines {
+				if l.key != "" {
+					distro[l.key] = l.value
+				}
+			}
+		}
+		cfg.Distros[name] = distro
+	}
+
+	return cfg, nil
+}
+
+func getOr(doc *document, section, key string) string {
+	v, _ := doc.get(section, key)
+	return v
+}
+
+// SetKernel merges a new kernel path into [wsl2], leaving every other
+// setting untouched.
+func (c *WSLConfig) SetKernel(path string) {
+	c.WSL2.Kernel = path
+	c.doc.set("wsl2", "kernel", path)
+}
+
+// SetMemory merges a new memory limit into [wsl2] (e.g. "8GB").
+func (c *WSLConfig) SetMemory(memory string) {
+	c.WSL2.Memory = memory
+	c.doc.set("wsl2", "memory", memory)
+}
+
+// SetProcessors merges a new processor count into [wsl2].
+func (c *WSLConfig) SetProcessors(processors string) {
+	c.WSL2.Processors = processors
+	c.doc.set("wsl2", "processors", processors)
+}
+
+// SetSwap merges a new swap size into [wsl2] (e.g. "2GB").
+func (c *WSLConfig) SetSwap(swap string) {
+	c.WSL2.Swap = swap
+	c.doc.set("wsl2", "swap", swap)
+}
+
+// SetNetworkingMode merges a new networkingMode into [wsl2]
+// (e.g. "mirrored" or "nat").
+func (c *WSLConfig) SetNetworkingMode(mode string) {
+	c.WSL2.NetworkingMode = mode
+	c.doc.set("wsl2", "networkingMode", mode)
+}
+
+// SetDNSTunneling merges dnsTunneling=true/false into [wsl2].
+func (c *WSLConfig) SetDNSTunneling(enabled bool) {
+	value := strconv.FormatBool(enabled)
+	c.WSL2.DNSTunneling = value
+	c.doc.set("wsl2", "dnsTunneling", value)
+}
+
+// Save atomically writes the config back to disk: it renders to a temp file
+// in the same directory and os.Renames it into place, and keeps a
+// timestamped backup of whatever was there before.
+func (c *WSLConfig) Save() error {
+	return c.SaveWith(os.WriteFile)
+}
+
+// SaveWith is like Save but delegates the final write to writeFile instead
+// of os.WriteFile, so callers that need a platform-specific encoding (e.g.
+// UTF-16 LE with BOM on Windows) can still get the same atomic-write-plus-
+// backup semantics.
+func (c *WSLConfig) SaveWith(writeFile func(path string, data []byte, perm os.FileMode) error) error {
+	if _, err := os.Stat(c.path); err == nil {
+		backupPath := fmt.Sprintf("%s.bak.%d", c.path, time.Now().Unix())
+		if err := copyFile(c.path, backupPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", c.path, err)
+		}
+	}
+
+	dir := filepath.Dir(c.path)
+	tmp, err := os.CreateTemp(dir, ".wslconfig.tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %v", c.path, err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if err := writeFile(tmpPath, []byte(c.doc.render()), 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %v", c.path, err)
+	}
+
+	return nil
+}
+
+// Rollback restores the most recent `.bak.<epoch>` backup of path over path
+// itself.
+func Rollback(path string) error {
+	matches, err := filepath.Glob(path + ".bak.*")
+	if err != nil {
+		return fmt.Errorf("failed to list backups for %s: %v", path, err)
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no backups found for %s", path)
+	}
+
+	sort.Strings(matches)
+	latest := matches[len(matches)-1]
+
+	if err := copyFile(latest, path); err != nil {
+		return fmt.Errorf("failed to restore %s from %s: %v", path, latest, err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
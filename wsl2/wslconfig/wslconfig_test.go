@@ -0,0 +1,123 @@
+package wslconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetKernelRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".wslconfig")
+
+	original := `# user comment
+[wsl2]
+memory=4GB
+processors=2
+; semicolon comment
+
+[experimental]
+sparseVhd=true
+
+[Ubuntu-22.04]
+customKey=customValue
+`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to seed %s: %v", path, err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg.SetKernel(`C:\bzImage`)
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	rendered, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s back: %v", path, err)
+	}
+	out := string(rendered)
+
+	for _, want := range []string{
+		"# user comment",
+		"; semicolon comment",
+		"memory=4GB",
+		"processors=2",
+		`kernel=C:\bzImage`,
+		"[experimental]",
+		"sparseVhd=true",
+		"[Ubuntu-22.04]",
+		"customKey=customValue",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered config missing %q, got:\n%s", want, out)
+		}
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloaded.WSL2.Kernel != `C:\bzImage` {
+		t.Errorf("WSL2.Kernel = %q, want C:\\bzImage", reloaded.WSL2.Kernel)
+	}
+	if reloaded.WSL2.Memory != "4GB" {
+		t.Errorf("WSL2.Memory = %q, want 4GB to survive untouched", reloaded.WSL2.Memory)
+	}
+	if reloaded.Experimental.SparseVHD != "true" {
+		t.Errorf("Experimental.SparseVHD = %q, want true", reloaded.Experimental.SparseVHD)
+	}
+
+	distro, ok := reloaded.Distros["Ubuntu-22.04"]
+	if !ok {
+		t.Fatal("Ubuntu-22.04 distro section lost on round trip")
+	}
+	if distro["customKey"] != "customValue" {
+		t.Errorf("Ubuntu-22.04 customKey = %q, want customValue", distro["customKey"])
+	}
+}
+
+func TestRollbackPicksLatestBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".wslconfig")
+
+	current := "[wsl2]\nkernel=C:\\current\n"
+	if err := os.WriteFile(path, []byte(current), 0644); err != nil {
+		t.Fatalf("failed to seed %s: %v", path, err)
+	}
+
+	older := "[wsl2]\nkernel=C:\\older\n"
+	newer := "[wsl2]\nkernel=C:\\newer\n"
+	if err := os.WriteFile(path+".bak.1700000000", []byte(older), 0644); err != nil {
+		t.Fatalf("failed to seed older backup: %v", err)
+	}
+	if err := os.WriteFile(path+".bak.1700000100", []byte(newer), 0644); err != nil {
+		t.Fatalf("failed to seed newer backup: %v", err)
+	}
+
+	if err := Rollback(path); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s back: %v", path, err)
+	}
+	if string(got) != newer {
+		t.Errorf("Rollback restored %q, want the newer backup %q", got, newer)
+	}
+}
+
+func TestRollbackNoBackupsFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".wslconfig")
+	if err := os.WriteFile(path, []byte("[wsl2]\n"), 0644); err != nil {
+		t.Fatalf("failed to seed %s: %v", path, err)
+	}
+
+	if err := Rollback(path); err == nil {
+		t.Error("Rollback with no backups present should fail, got nil")
+	}
+}